@@ -0,0 +1,281 @@
+// Package filterfs wraps a billy.Filesystem with a predicate that masks
+// individual paths as absent, read-only, or silently discarded. This
+// lets fixture consumers build partial views of a repo (e.g. "expose
+// only .git/objects and .git/refs") without materializing new copies -
+// useful for testing sparse-checkout, partial clone, and other
+// selective-fetch code paths.
+package filterfs
+
+import (
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// Op identifies the kind of operation a Predicate is being asked to
+// judge for a given path.
+type Op int
+
+const (
+	// OpRead covers Open, Stat, Lstat, Readlink and ReadDir entries.
+	OpRead Op = iota
+	// OpWrite covers Create, OpenFile with write flags, Remove, Rename,
+	// MkdirAll, TempFile and Symlink.
+	OpWrite
+)
+
+// Decision is the outcome a Predicate returns for a path/Op pair.
+type Decision int
+
+const (
+	// Allow lets the operation through to the wrapped filesystem.
+	Allow Decision = iota
+	// Hide makes the path appear absent, regardless of Op.
+	Hide
+	// Discard returns a no-op file that swallows writes and reports
+	// zero-length reads, without touching the wrapped filesystem.
+	Discard
+	// ReadOnly allows reads but fails mutating operations with
+	// billy.ErrReadOnly.
+	ReadOnly
+)
+
+// Predicate decides how path should be treated for the given Op.
+type Predicate func(path string, op Op) Decision
+
+// Filter wraps inner, applying predicate to every operation.
+type Filter struct {
+	inner     billy.Filesystem
+	predicate Predicate
+}
+
+// New returns a billy.Filesystem that filters inner through predicate.
+func New(inner billy.Filesystem, predicate Predicate) *Filter {
+	return &Filter{inner: inner, predicate: predicate}
+}
+
+func (f *Filter) Create(filename string) (billy.File, error) {
+	switch f.predicate(filename, OpWrite) {
+	case Hide:
+		return nil, os.ErrNotExist
+	case Discard:
+		return newDiscardFile(filename), nil
+	case ReadOnly:
+		return nil, billy.ErrReadOnly
+	default:
+		return f.inner.Create(filename)
+	}
+}
+
+func (f *Filter) Open(filename string) (billy.File, error) {
+	switch f.predicate(filename, OpRead) {
+	case Hide:
+		return nil, os.ErrNotExist
+	case Discard:
+		return newDiscardFile(filename), nil
+	default:
+		return f.inner.Open(filename)
+	}
+}
+
+func (f *Filter) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	op := OpRead
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0
+	if write {
+		op = OpWrite
+	}
+
+	switch f.predicate(filename, op) {
+	case Hide:
+		return nil, os.ErrNotExist
+	case Discard:
+		return newDiscardFile(filename), nil
+	case ReadOnly:
+		if write {
+			return nil, billy.ErrReadOnly
+		}
+		return f.inner.OpenFile(filename, flag, perm)
+	default:
+		return f.inner.OpenFile(filename, flag, perm)
+	}
+}
+
+func (f *Filter) Stat(filename string) (os.FileInfo, error) {
+	if f.predicate(filename, OpRead) == Hide {
+		return nil, os.ErrNotExist
+	}
+	return f.inner.Stat(filename)
+}
+
+func (f *Filter) Rename(from, to string) error {
+	switch max(f.predicate(from, OpWrite), f.predicate(to, OpWrite)) {
+	case Hide:
+		return os.ErrNotExist
+	case ReadOnly:
+		return billy.ErrReadOnly
+	case Discard:
+		return nil
+	default:
+		return f.inner.Rename(from, to)
+	}
+}
+
+func (f *Filter) Remove(filename string) error {
+	switch f.predicate(filename, OpWrite) {
+	case Hide:
+		return os.ErrNotExist
+	case ReadOnly:
+		return billy.ErrReadOnly
+	case Discard:
+		return nil
+	default:
+		return f.inner.Remove(filename)
+	}
+}
+
+func (f *Filter) Join(elem ...string) string {
+	return f.inner.Join(elem...)
+}
+
+func (f *Filter) ReadDir(path string) ([]os.FileInfo, error) {
+	if f.predicate(path, OpRead) == Hide {
+		return nil, os.ErrNotExist
+	}
+
+	entries, err := f.inner.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]os.FileInfo, 0, len(entries))
+	for _, fi := range entries {
+		if f.predicate(f.inner.Join(path, fi.Name()), OpRead) == Hide {
+			continue
+		}
+		filtered = append(filtered, fi)
+	}
+
+	return filtered, nil
+}
+
+func (f *Filter) MkdirAll(path string, perm os.FileMode) error {
+	switch f.predicate(path, OpWrite) {
+	case Hide:
+		return os.ErrNotExist
+	case ReadOnly:
+		return billy.ErrReadOnly
+	case Discard:
+		return nil
+	default:
+		return f.inner.MkdirAll(path, perm)
+	}
+}
+
+func (f *Filter) TempFile(dir, prefix string) (billy.File, error) {
+	switch f.predicate(dir, OpWrite) {
+	case Hide:
+		return nil, os.ErrNotExist
+	case Discard:
+		return newDiscardFile(f.inner.Join(dir, prefix)), nil
+	case ReadOnly:
+		return nil, billy.ErrReadOnly
+	default:
+		return f.inner.TempFile(dir, prefix)
+	}
+}
+
+func (f *Filter) Symlink(target, link string) error {
+	sfs, ok := f.inner.(billy.Symlink)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	switch f.predicate(link, OpWrite) {
+	case Hide:
+		return os.ErrNotExist
+	case ReadOnly:
+		return billy.ErrReadOnly
+	case Discard:
+		return nil
+	default:
+		return sfs.Symlink(target, link)
+	}
+}
+
+func (f *Filter) Readlink(link string) (string, error) {
+	if f.predicate(link, OpRead) == Hide {
+		return "", os.ErrNotExist
+	}
+
+	sfs, ok := f.inner.(billy.Symlink)
+	if !ok {
+		return "", billy.ErrNotSupported
+	}
+
+	return sfs.Readlink(link)
+}
+
+func (f *Filter) Lstat(filename string) (os.FileInfo, error) {
+	if f.predicate(filename, OpRead) == Hide {
+		return nil, os.ErrNotExist
+	}
+
+	sfs, ok := f.inner.(billy.Symlink)
+	if !ok {
+		return f.inner.Stat(filename)
+	}
+
+	return sfs.Lstat(filename)
+}
+
+// Chroot returns a filtered view of inner.Chroot(path). The predicate
+// keeps applying to whatever paths are subsequently passed in, which
+// after a Chroot are relative to path rather than the original root.
+func (f *Filter) Chroot(path string) (billy.Filesystem, error) {
+	root, err := f.inner.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(root, f.predicate), nil
+}
+
+func (f *Filter) Root() string {
+	return f.inner.Root()
+}
+
+// Capabilities implements the billy.Capable interface.
+func (f *Filter) Capabilities() billy.Capability {
+	if cfs, ok := f.inner.(billy.Capable); ok {
+		return cfs.Capabilities()
+	}
+	return billy.DefaultCapabilities
+}
+
+func max(a, b Decision) Decision {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// discardFile is a no-op billy.File returned for paths the Predicate
+// decided to Discard: writes succeed without storing anything, reads
+// report zero-length content.
+type discardFile struct {
+	name string
+}
+
+func newDiscardFile(name string) billy.File {
+	return &discardFile{name: name}
+}
+
+func (d *discardFile) Name() string                                 { return d.name }
+func (d *discardFile) Write(p []byte) (int, error)                  { return len(p), nil }
+func (d *discardFile) Read(p []byte) (int, error)                   { return 0, io.EOF }
+func (d *discardFile) ReadAt(p []byte, off int64) (int, error)      { return 0, io.EOF }
+func (d *discardFile) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+func (d *discardFile) Close() error                                 { return nil }
+func (d *discardFile) Lock() error                                  { return nil }
+func (d *discardFile) Unlock() error                                { return nil }
+func (d *discardFile) Truncate(size int64) error                    { return nil }
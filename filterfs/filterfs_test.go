@@ -0,0 +1,129 @@
+package filterfs
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+func seed(t *testing.T, fs billy.Filesystem, name, contents string) {
+	t.Helper()
+
+	f, err := fs.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", name, err)
+	}
+	if _, err := f.Write([]byte(contents)); err != nil {
+		t.Fatalf("Write(%s): %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", name, err)
+	}
+}
+
+func TestHideMakesPathAppearAbsent(t *testing.T) {
+	inner := memfs.New()
+	seed(t, inner, "secret.txt", "nope")
+
+	f := New(inner, func(path string, op Op) Decision {
+		if path == "secret.txt" {
+			return Hide
+		}
+		return Allow
+	})
+
+	if _, err := f.Open("secret.txt"); !os.IsNotExist(err) {
+		t.Fatalf("Open(secret.txt) = %v, want os.ErrNotExist", err)
+	}
+	if _, err := f.Stat("secret.txt"); !os.IsNotExist(err) {
+		t.Fatalf("Stat(secret.txt) = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestDiscardSwallowsWritesAndReportsEmptyReads(t *testing.T) {
+	inner := memfs.New()
+
+	f := New(inner, func(path string, op Op) Decision {
+		return Discard
+	})
+
+	wf, err := f.Create("anything.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	n, err := wf.Write([]byte("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("Write = %d, %v; want 5, nil", n, err)
+	}
+	if err := wf.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := inner.Stat("anything.txt"); !os.IsNotExist(err) {
+		t.Fatalf("Discard leaked a write through to inner: Stat = %v", err)
+	}
+
+	data, err := io.ReadAll(wf)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("discarded file returned %d bytes, want 0", len(data))
+	}
+}
+
+func TestReadOnlyAllowsReadsAndRejectsWrites(t *testing.T) {
+	inner := memfs.New()
+	seed(t, inner, "ro.txt", "fixed")
+
+	f := New(inner, func(path string, op Op) Decision {
+		return ReadOnly
+	})
+
+	rf, err := f.Open("ro.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(rf)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(data) != "fixed" {
+		t.Fatalf("ro.txt = %q, want %q", data, "fixed")
+	}
+	rf.Close()
+
+	if _, err := f.Create("ro.txt"); err != billy.ErrReadOnly {
+		t.Fatalf("Create under ReadOnly = %v, want billy.ErrReadOnly", err)
+	}
+	if err := f.Remove("ro.txt"); err != billy.ErrReadOnly {
+		t.Fatalf("Remove under ReadOnly = %v, want billy.ErrReadOnly", err)
+	}
+}
+
+func TestReadDirFiltersHiddenChildren(t *testing.T) {
+	inner := memfs.New()
+	seed(t, inner, "visible.txt", "v")
+	seed(t, inner, "hidden.txt", "h")
+
+	f := New(inner, func(path string, op Op) Decision {
+		if path == "hidden.txt" {
+			return Hide
+		}
+		return Allow
+	})
+
+	entries, err := f.ReadDir("")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	for _, fi := range entries {
+		if fi.Name() == "hidden.txt" {
+			t.Fatal("ReadDir listed a Hide-predicated entry")
+		}
+	}
+}
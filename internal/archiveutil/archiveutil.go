@@ -0,0 +1,210 @@
+// Package archiveutil implements the format-agnostic half of extracting
+// an archive (tar, zip, ...) onto a billy.Filesystem: path-safety
+// checks, size limiting, and writing directories/files/symlinks/
+// hardlinks. Format-specific packages (tgz, zip) adapt their own
+// reader into a NextFunc and delegate the actual walk to Extract.
+package archiveutil
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// ErrSymlinkFallback is wrapped into the error returned by Extract when
+// the destination filesystem does not implement billy.Symlink.
+// Extraction is not aborted in this case: the offending entry is written
+// as a regular file whose contents are the link target, and the caller
+// can inspect the returned error (e.g. with errors.Is) to learn that the
+// fallback was used.
+var ErrSymlinkFallback = errors.New("archiveutil: filesystem does not support symlinks, link target written as file contents")
+
+// Warnings wraps one or more non-fatal issues (currently only
+// ErrSymlinkFallback entries) encountered while otherwise successfully
+// extracting an archive. Unlike any other error Extract can return, a
+// Warnings means the full archive was written below dstPath and the
+// destination is safe to use - callers that care about degraded entries
+// can inspect it with errors.As, everyone else can treat it as success.
+type Warnings struct {
+	errs []error
+}
+
+func (w *Warnings) Error() string {
+	return errors.Join(w.errs...).Error()
+}
+
+func (w *Warnings) Unwrap() []error {
+	return w.errs
+}
+
+// Options bounds the resources consumed while extracting an archive.
+type Options struct {
+	// MaxExtractedSize limits the total number of bytes that may be
+	// written while extracting the archive, guarding against
+	// decompression bombs. Zero means no limit.
+	MaxExtractedSize int64
+}
+
+// Entry describes a single archive member in a format-agnostic way.
+// Reader is only valid until the following call to the NextFunc that
+// produced this Entry, mirroring the archive/tar.Reader contract.
+type Entry struct {
+	Name     string
+	Mode     os.FileMode
+	Typeflag byte // archive/tar type constants: TypeDir, TypeReg, TypeSymlink, TypeLink, TypeXGlobalHeader
+	Linkname string
+	Reader   io.Reader
+}
+
+// NextFunc returns the next entry in the archive, or io.EOF once
+// exhausted.
+type NextFunc func() (Entry, error)
+
+// Extract walks next, writing every entry below dstPath on fs. It is
+// shared by the tgz and zip packages so that path-safety and
+// size-limiting logic only needs to be implemented once.
+func Extract(fs billy.Filesystem, dstPath string, next NextFunc, opts Options) error {
+	var written int64
+	var warnings []error
+
+	for {
+		entry, err := next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		if entry.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		dst, err := safeDest(dstPath, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		switch entry.Typeflag {
+		case tar.TypeDir:
+			if err := fs.MkdirAll(dst, entry.Mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			n, err := writeFile(fs, dst, entry.Mode, entry.Reader, remaining(opts, written))
+			if err != nil {
+				return err
+			}
+			written += n
+		case tar.TypeSymlink:
+			if err := writeSymlink(fs, dst, entry.Name, entry.Linkname, &warnings); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			n, err := writeHardlink(fs, dst, dstPath, entry, remaining(opts, written))
+			if err != nil {
+				return err
+			}
+			written += n
+		default:
+			return fmt.Errorf("archiveutil: unsupported entry type %c in %s", entry.Typeflag, entry.Name)
+		}
+	}
+
+	if len(warnings) == 0 {
+		return nil
+	}
+	return &Warnings{errs: warnings}
+}
+
+// safeDest joins name onto dstPath, rejecting absolute paths and any
+// path that, once cleaned, would escape dstPath ("tar slip").
+func safeDest(dstPath, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("archiveutil: refusing to extract %q: absolute path", name)
+	}
+
+	dst := filepath.Clean(filepath.Join(dstPath, name))
+	base := filepath.Clean(dstPath)
+	if dst != base && !strings.HasPrefix(dst, base+string(filepath.Separator)) {
+		return "", fmt.Errorf("archiveutil: refusing to extract %q: escapes destination", name)
+	}
+
+	return dst, nil
+}
+
+// remaining returns the number of bytes still allowed to be written
+// given opts.MaxExtractedSize, or -1 if there is no limit.
+func remaining(opts Options, written int64) int64 {
+	if opts.MaxExtractedSize <= 0 {
+		return -1
+	}
+	return opts.MaxExtractedSize - written
+}
+
+func writeSymlink(fs billy.Filesystem, dst, name, linkname string, warnings *[]error) error {
+	if sfs, ok := fs.(billy.Symlink); ok {
+		return sfs.Symlink(linkname, dst)
+	}
+
+	*warnings = append(*warnings, fmt.Errorf("%s: %w", name, ErrSymlinkFallback))
+	_, err := writeFile(fs, dst, 0644, strings.NewReader(linkname), -1)
+	return err
+}
+
+func writeHardlink(fs billy.Filesystem, dst, dstPath string, entry Entry, limit int64) (int64, error) {
+	src, err := safeDest(dstPath, entry.Linkname)
+	if err != nil {
+		return 0, err
+	}
+
+	r, err := fs.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("archiveutil: cannot open hardlink target %q: %w", entry.Linkname, err)
+	}
+	defer r.Close()
+
+	return writeFile(fs, dst, entry.Mode, r, limit)
+}
+
+// writeFile writes contents to path, stopping with an error once more
+// than limit bytes have been written. A negative limit means unlimited.
+// It returns the number of bytes written.
+func writeFile(fs billy.Filesystem, path string, mode os.FileMode, contents io.Reader, limit int64) (n int64, err error) {
+	w, err := fs.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		errClose := w.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	if limit >= 0 {
+		n, err = io.Copy(w, io.LimitReader(contents, limit+1))
+		if err == nil && n > limit {
+			return n, fmt.Errorf("archiveutil: extracted size exceeds the %d byte limit", limit)
+		}
+	} else {
+		n, err = io.Copy(w, contents)
+	}
+	if err != nil {
+		return n, err
+	}
+
+	if cfs, ok := fs.(billy.Change); ok {
+		if err = cfs.Chmod(path, mode); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package archiveutil
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+func TestSafeDestRejectsEscape(t *testing.T) {
+	cases := []string{
+		"/etc/passwd",
+		"../escape",
+		"a/../../escape",
+	}
+
+	for _, name := range cases {
+		if _, err := safeDest("/root", name); err == nil {
+			t.Errorf("safeDest(%q): expected error, got nil", name)
+		}
+	}
+}
+
+func TestSafeDestAllowsNested(t *testing.T) {
+	dst, err := safeDest("/root", "a/b/c.txt")
+	if err != nil {
+		t.Fatalf("safeDest: %v", err)
+	}
+	if dst != "/root/a/b/c.txt" {
+		t.Fatalf("safeDest = %q, want /root/a/b/c.txt", dst)
+	}
+}
+
+func entries(es ...Entry) NextFunc {
+	i := 0
+	return func() (Entry, error) {
+		if i >= len(es) {
+			return Entry{}, io.EOF
+		}
+		e := es[i]
+		i++
+		return e, nil
+	}
+}
+
+func TestExtractRejectsTarSlip(t *testing.T) {
+	fs := memfs.New()
+
+	err := Extract(fs, "/dst", entries(Entry{
+		Name:     "../escape.txt",
+		Typeflag: tar.TypeReg,
+		Reader:   strings.NewReader("pwned"),
+	}), Options{})
+
+	if err == nil {
+		t.Fatal("expected Extract to reject a tar-slip entry, got nil error")
+	}
+}
+
+func TestExtractEnforcesMaxExtractedSize(t *testing.T) {
+	fs := memfs.New()
+
+	err := Extract(fs, "/dst", entries(Entry{
+		Name:     "big.bin",
+		Typeflag: tar.TypeReg,
+		Reader:   strings.NewReader("0123456789"),
+	}), Options{MaxExtractedSize: 4})
+
+	if err == nil {
+		t.Fatal("expected Extract to enforce MaxExtractedSize, got nil error")
+	}
+}
+
+func TestExtractWritesSymlinks(t *testing.T) {
+	fs := memfs.New()
+
+	err := Extract(fs, "/dst", entries(Entry{
+		Name:     "link",
+		Typeflag: tar.TypeSymlink,
+		Linkname: "target",
+	}), Options{})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	target, err := fs.Readlink("/dst/link")
+	if err != nil {
+		t.Fatalf("Readlink: %v", err)
+	}
+	if target != "target" {
+		t.Fatalf("Readlink(/dst/link) = %q, want %q", target, "target")
+	}
+}
+
+func TestWarningsUnwrapsAndJoinsMessages(t *testing.T) {
+	w := &Warnings{errs: []error{
+		fmt.Errorf("a: %w", ErrSymlinkFallback),
+		fmt.Errorf("b: %w", ErrSymlinkFallback),
+	}}
+
+	if !errors.Is(w, ErrSymlinkFallback) {
+		t.Fatal("expected Warnings to unwrap to ErrSymlinkFallback")
+	}
+	if !strings.Contains(w.Error(), "a:") || !strings.Contains(w.Error(), "b:") {
+		t.Fatalf("Warnings.Error() = %q, want both wrapped messages present", w.Error())
+	}
+}
@@ -0,0 +1,246 @@
+package tgz
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+const (
+	completeSentinel = ".complete"
+	partialSuffix    = ".partial"
+	lockSuffix       = ".lock"
+	lockPollInterval = 50 * time.Millisecond
+	// lockStaleAfter bounds how long a lock file is honoured for. A
+	// process that dies between creating the lock and releasing it would
+	// otherwise wedge every future ExtractCached call for that digest;
+	// once a lock is older than this it is assumed abandoned and stolen.
+	lockStaleAfter = 30 * time.Second
+)
+
+// ExtractCached behaves like Extract, but memoizes the result under
+// cacheDir, keyed by the SHA-256 digest of tgz's bytes. Test suites that
+// extract the same fixture many times can reuse the cached directory
+// instead of paying the untar cost on every call.
+//
+// cacheDir must be a real, absolute path on disk, and fs must resolve
+// paths the same way the os package does (e.g. osfs.New("/")): locking,
+// fsync and the final rename are all done directly against cacheDir via
+// os, while extraction itself goes through fs, so the two must agree on
+// where cacheDir actually is. The returned cleanup is always a no-op -
+// cached extractions are meant to outlive the call that produced them;
+// reclaim space with PurgeCache instead.
+func ExtractCached(fs billy.Filesystem, tgz, cacheDir string) (d billy.Filesystem, err error, cleanup func()) {
+	cleanup = func() {}
+
+	f, err := fs.Open(tgz)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := f.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	finalDir := filepath.Join(cacheDir, digest)
+	if isComplete(finalDir) {
+		d, err = fs.Chroot(finalDir)
+		return
+	}
+
+	unlock, err := lockDigest(cacheDir, digest)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errUnlock := unlock()
+		if err == nil {
+			err = errUnlock
+		}
+	}()
+
+	// Another caller may have finished the extraction while we were
+	// waiting for the lock.
+	if isComplete(finalDir) {
+		d, err = fs.Chroot(finalDir)
+		return
+	}
+
+	partialDir := finalDir + partialSuffix
+	_ = os.RemoveAll(partialDir)
+	if err = fs.MkdirAll(partialDir, 0755); err != nil {
+		return
+	}
+
+	// Reuse the handle already drained into h above instead of opening
+	// and reading tgz a second time.
+	seeker, ok := f.(io.Seeker)
+	if !ok {
+		err = fmt.Errorf("tgz: ExtractCached requires a seekable file, got %T", f)
+		return
+	}
+	if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	if err = extractFromReader(fs, f, partialDir, ExtractOptions{}); err != nil {
+		return
+	}
+
+	if err = fsyncTree(partialDir); err != nil {
+		return
+	}
+
+	if err = os.Rename(partialDir, finalDir); err != nil {
+		return
+	}
+
+	if err = os.WriteFile(filepath.Join(finalDir, completeSentinel), nil, 0644); err != nil {
+		return
+	}
+
+	d, err = fs.Chroot(finalDir)
+	return
+}
+
+// PurgeCache removes cache entries under cacheDir that were completed
+// more than maxAge ago, along with *.partial and *.lock leftovers from
+// runs that were interrupted before completion. It is meant to be run
+// out-of-band (e.g. a periodic CI cleanup step), not from inside
+// ExtractCached.
+func PurgeCache(cacheDir string, maxAge time.Duration) error {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+
+	for _, e := range entries {
+		name := e.Name()
+		full := filepath.Join(cacheDir, name)
+
+		if strings.HasSuffix(name, partialSuffix) || strings.HasSuffix(name, lockSuffix) {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			if info.ModTime().Before(cutoff) {
+				_ = os.RemoveAll(full)
+			}
+			continue
+		}
+
+		if !e.IsDir() {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(full, completeSentinel))
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			_ = os.RemoveAll(full)
+		}
+	}
+
+	return nil
+}
+
+func isComplete(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, completeSentinel))
+	return err == nil
+}
+
+// lockDigest acquires an advisory, per-digest lock under cacheDir so
+// that concurrent ExtractCached calls for the same archive do not race
+// to extract it: only the first caller does the work, the rest block
+// until it releases the lock and then find the result already cached.
+//
+// The lock is a plain exclusively-created file rather than a kernel
+// flock, so it does not release itself if the owning process crashes;
+// to bound that, any lock older than lockStaleAfter is treated as
+// abandoned and stolen by the next waiter.
+func lockDigest(cacheDir, digest string) (unlock func() error, err error) {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	lockPath := filepath.Join(cacheDir, digest+lockSuffix)
+	for {
+		lf, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(lf, "%d\n", os.Getpid())
+
+			return func() error {
+				errClose := lf.Close()
+				errRemove := os.Remove(lockPath)
+				if errClose != nil {
+					return errClose
+				}
+				if errRemove != nil && !os.IsNotExist(errRemove) {
+					return errRemove
+				}
+				return nil
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		stealStaleLock(lockPath)
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// stealStaleLock removes lockPath if it has not been touched in
+// lockStaleAfter, meaning whatever process created it is long gone.
+func stealStaleLock(lockPath string) {
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return
+	}
+	if time.Since(info.ModTime()) < lockStaleAfter {
+		return
+	}
+	_ = os.Remove(lockPath)
+}
+
+// fsyncTree fsyncs every regular file under root, so that a subsequent
+// rename of root is durable even across a crash.
+func fsyncTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return f.Sync()
+	})
+}
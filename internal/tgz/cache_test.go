@@ -0,0 +1,133 @@
+package tgz
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+)
+
+// ExtractCached shells out to os.Rename/os.WriteFile/os.Open for locking
+// and atomic commit using cacheDir's absolute path directly, so fs must
+// resolve paths the same way - i.e. be rooted at "/" - or the billy-side
+// writes under cacheDir and the os-side rename/fsync would land in two
+// unrelated trees.
+func newCacheTestFS(t *testing.T) billy.Filesystem {
+	t.Helper()
+	return osfs.New("/")
+}
+
+func TestExtractCachedReusesCompletedEntry(t *testing.T) {
+	fs := newCacheTestFS(t)
+	writeFixture(t, fs, "fixture.tgz", writeTgz(t, map[string]string{"hello.txt": "hello"}))
+	cacheDir := t.TempDir()
+
+	d1, err, _ := ExtractCached(fs, "fixture.tgz", cacheDir)
+	if err != nil {
+		t.Fatalf("first ExtractCached: %v", err)
+	}
+	assertFileContents(t, d1, "hello.txt", "hello")
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("ReadDir(cacheDir): %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("cacheDir has %d entries after first extraction, want 1: %v", len(entries), entries)
+	}
+	digest := entries[0].Name()
+
+	d2, err, _ := ExtractCached(fs, "fixture.tgz", cacheDir)
+	if err != nil {
+		t.Fatalf("second ExtractCached: %v", err)
+	}
+	assertFileContents(t, d2, "hello.txt", "hello")
+
+	entries, err = os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("ReadDir(cacheDir) after reuse: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != digest {
+		t.Fatalf("cacheDir contents changed on cache hit: %v", entries)
+	}
+}
+
+func TestExtractCachedConcurrentCallersAgreeOnResult(t *testing.T) {
+	fs := newCacheTestFS(t)
+	writeFixture(t, fs, "fixture.tgz", writeTgz(t, map[string]string{"hello.txt": "hello"}))
+	cacheDir := t.TempDir()
+
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d, err, _ := ExtractCached(fs, "fixture.tgz", cacheDir)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			f, err := d.Open("hello.txt")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer f.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("ReadDir(cacheDir): %v", err)
+	}
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) == lockSuffix || filepath.Ext(e.Name()) == partialSuffix {
+			t.Errorf("leftover cache entry after concurrent extraction: %s", e.Name())
+		}
+	}
+}
+
+func TestLockDigestStealsStaleLock(t *testing.T) {
+	cacheDir := t.TempDir()
+	lockPath := filepath.Join(cacheDir, "deadbeef"+lockSuffix)
+
+	if err := os.WriteFile(lockPath, []byte("12345\n"), 0644); err != nil {
+		t.Fatalf("seed stale lock: %v", err)
+	}
+	oldTime := time.Now().Add(-2 * lockStaleAfter)
+	if err := os.Chtimes(lockPath, oldTime, oldTime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		unlock, err := lockDigest(cacheDir, "deadbeef")
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- unlock()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("lockDigest: %v", err)
+		}
+	case <-time.After(lockStaleAfter + 5*time.Second):
+		t.Fatal("lockDigest did not steal the stale lock in time")
+	}
+}
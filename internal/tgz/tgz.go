@@ -3,12 +3,14 @@ package tgz
 import (
 	"archive/tar"
 	"compress/gzip"
-	"fmt"
+	"errors"
 	"io"
 	"os"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/pjbgf/go-git-fixtures/v5/internal/archiveutil"
 )
 
 const (
@@ -16,6 +18,16 @@ const (
 	tmpPrefix         = "tmp-tgz-"
 )
 
+// ErrSymlinkFallback is returned, potentially wrapped, by Extract when the
+// destination filesystem does not implement billy.Symlink. Extraction is
+// not aborted in this case: the offending entry is written as a regular
+// file whose contents are the link target, and the caller can inspect the
+// returned error (e.g. with errors.Is) to learn that the fallback was used.
+var ErrSymlinkFallback = archiveutil.ErrSymlinkFallback
+
+// ExtractOptions customises the behaviour of ExtractWithOptions.
+type ExtractOptions = archiveutil.Options
+
 // Extract decompress a gziped tarball into a new temporal directory
 // created just for this purpose.
 //
@@ -28,6 +40,12 @@ const (
 // Otherwise, a non-empty string with the temporal directory holding
 // whatever information was extracted before the error is returned.
 func Extract(fs billy.Filesystem, tgz string) (d billy.Filesystem, err error, cleanup func()) {
+	return ExtractWithOptions(fs, tgz, ExtractOptions{})
+}
+
+// ExtractWithOptions behaves like Extract, but lets the caller bound the
+// amount of data written to disk via opts.MaxExtractedSize.
+func ExtractWithOptions(fs billy.Filesystem, tgz string, opts ExtractOptions) (d billy.Filesystem, err error, cleanup func()) {
 	dirName := ""
 	cleanup = func() {
 		if dirName != "" {
@@ -35,99 +53,86 @@ func Extract(fs billy.Filesystem, tgz string) (d billy.Filesystem, err error, cl
 		}
 	}
 
-	f, err := fs.Open(tgz)
+	dirName, err = util.TempDir(fs, useDefaultTempDir, tmpPrefix)
 	if err != nil {
 		return
 	}
 
-	defer func() {
-		errClose := f.Close()
-		if err == nil {
-			err = errClose
-		}
-	}()
+	extractErr := extractInto(fs, tgz, dirName, opts)
 
-	dirName, err = util.TempDir(fs, useDefaultTempDir, tmpPrefix)
-	if err != nil {
+	var warnings *archiveutil.Warnings
+	if extractErr != nil && !errors.As(extractErr, &warnings) {
+		err = extractErr
 		return
 	}
 
-	tar, err := zipTarReader(f)
+	// extractErr is either nil or a non-fatal *archiveutil.Warnings: the
+	// archive was fully extracted either way, so dirName is safe to
+	// chroot into. Hand the warning back to the caller alongside it.
+	d, err = fs.Chroot(dirName)
 	if err != nil {
 		return
 	}
-
-	if err = unTar(fs, tar, dirName); err != nil {
-		return
-	}
-
-	d, err = fs.Chroot(dirName)
+	err = extractErr
 	return
 }
 
-func zipTarReader(r io.Reader) (*tar.Reader, error) {
-	zip, err := gzip.NewReader(r)
+// extractInto untars tgz directly below dstPath, which must already
+// exist. It is used by ExtractWithOptions; ExtractCached goes through
+// extractFromReader directly.
+func extractInto(fs billy.Filesystem, tgz, dstPath string, opts ExtractOptions) (err error) {
+	f, err := fs.Open(tgz)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return tar.NewReader(zip), nil
-}
-
-func unTar(fs billy.Filesystem, src *tar.Reader, dstPath string) error {
-	for {
-		header, err := src.Next()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-
-		dst := dstPath + "/" + header.Name
-		mode := os.FileMode(header.Mode)
-		switch header.Typeflag {
-		case tar.TypeDir:
-			err := fs.MkdirAll(dst, mode)
-			if err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			err := makeFile(fs, dst, mode, src)
-			if err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("Unable to untar type : %c in file %s",
-				header.Typeflag, header.Name)
+	defer func() {
+		errClose := f.Close()
+		if err == nil {
+			err = errClose
 		}
-	}
+	}()
 
-	return nil
+	return extractFromReader(fs, f, dstPath, opts)
 }
 
-func makeFile(fs billy.Filesystem, path string, mode os.FileMode, contents io.Reader) (err error) {
-	w, err := fs.Create(path)
+// extractFromReader untars an already-open gzip stream directly below
+// dstPath. Split out from extractInto so ExtractCached can hash the
+// source once and reuse the same (seeked-back) reader to extract it,
+// instead of opening and reading the archive a second time.
+func extractFromReader(fs billy.Filesystem, r io.Reader, dstPath string, opts ExtractOptions) error {
+	tar, err := zipTarReader(r)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		errClose := w.Close()
-		if err == nil {
-			err = errClose
-		}
-	}()
 
-	_, err = io.Copy(w, contents)
+	return unTar(fs, tar, dstPath, opts)
+}
+
+func zipTarReader(r io.Reader) (*tar.Reader, error) {
+	zip, err := gzip.NewReader(r)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if fs, ok := fs.(billy.Change); ok {
-		if err = fs.Chmod(path, mode); err != nil {
-			return err
+	return tar.NewReader(zip), nil
+}
+
+// unTar adapts a *tar.Reader into an archiveutil.NextFunc and delegates
+// the actual walk-and-write to archiveutil.Extract.
+func unTar(fs billy.Filesystem, src *tar.Reader, dstPath string, opts archiveutil.Options) error {
+	return archiveutil.Extract(fs, dstPath, func() (archiveutil.Entry, error) {
+		header, err := src.Next()
+		if err != nil {
+			return archiveutil.Entry{}, err
 		}
-	}
 
-	return nil
+		return archiveutil.Entry{
+			Name:     header.Name,
+			Mode:     os.FileMode(header.Mode),
+			Typeflag: header.Typeflag,
+			Linkname: header.Linkname,
+			Reader:   src,
+		}, nil
+	}, opts)
 }
@@ -0,0 +1,118 @@
+package tgz
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+func writeTgz(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for name, body := range entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0644,
+			Size: int64(len(body)),
+		}); err != nil {
+			t.Fatalf("WriteHeader(%s): %v", name, err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip Close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeFixture(t *testing.T, fs billy.Filesystem, name string, data []byte) {
+	t.Helper()
+
+	f, err := fs.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("Write(%s): %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", name, err)
+	}
+}
+
+func assertFileContents(t *testing.T, fs billy.Filesystem, name, want string) {
+	t.Helper()
+
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", name, err)
+	}
+	defer f.Close()
+
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll(%s): %v", name, err)
+	}
+	if string(got) != want {
+		t.Fatalf("%s contents = %q, want %q", name, got, want)
+	}
+}
+
+func TestExtractWritesFiles(t *testing.T) {
+	fs := memfs.New()
+	writeFixture(t, fs, "fixture.tgz", writeTgz(t, map[string]string{
+		"hello.txt":     "hello",
+		"dir/world.txt": "world",
+	}))
+
+	d, err, cleanup := Extract(fs, "fixture.tgz")
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	assertFileContents(t, d, "hello.txt", "hello")
+	assertFileContents(t, d, "dir/world.txt", "world")
+}
+
+func TestExtractRejectsPathEscape(t *testing.T) {
+	fs := memfs.New()
+	writeFixture(t, fs, "evil.tgz", writeTgz(t, map[string]string{
+		"../../etc/passwd": "pwned",
+	}))
+
+	_, err, cleanup := Extract(fs, "evil.tgz")
+	defer cleanup()
+	if err == nil {
+		t.Fatal("expected Extract to reject a path escaping the destination, got nil error")
+	}
+}
+
+func TestExtractWithOptionsEnforcesSizeLimit(t *testing.T) {
+	fs := memfs.New()
+	writeFixture(t, fs, "big.tgz", writeTgz(t, map[string]string{
+		"big.bin": "0123456789",
+	}))
+
+	_, err, cleanup := ExtractWithOptions(fs, "big.tgz", ExtractOptions{MaxExtractedSize: 4})
+	defer cleanup()
+	if err == nil {
+		t.Fatal("expected ExtractWithOptions to fail once MaxExtractedSize is exceeded, got nil error")
+	}
+}
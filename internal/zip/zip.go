@@ -0,0 +1,169 @@
+// Package zip mirrors the tgz package, but sources fixtures from a .zip
+// archive instead of a gzipped tarball.
+package zip
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/pjbgf/go-git-fixtures/v5/internal/archiveutil"
+)
+
+const (
+	useDefaultTempDir = ""
+	tmpPrefix         = "tmp-zip-"
+)
+
+// ErrSymlinkFallback is returned, potentially wrapped, by Extract when the
+// destination filesystem does not implement billy.Symlink. See
+// tgz.ErrSymlinkFallback for the exact semantics.
+var ErrSymlinkFallback = archiveutil.ErrSymlinkFallback
+
+// ExtractOptions customises the behaviour of ExtractWithOptions.
+type ExtractOptions = archiveutil.Options
+
+// Extract decompresses a zip archive into a new temporal directory
+// created just for this purpose.
+//
+// On success, a billy.Filesystem rooted at the newly created directory
+// and a nil error are returned. The returned cleanup removes that
+// directory.
+//
+// If extraction fails, a nil billy.Filesystem and a non-nil error are
+// returned; cleanup still removes the temporal directory if it was
+// created. When the only issues were symlinks written as regular files,
+// the billy.Filesystem is returned together with an error wrapping
+// ErrSymlinkFallback.
+func Extract(fs billy.Filesystem, zipPath string) (d billy.Filesystem, err error, cleanup func()) {
+	return ExtractWithOptions(fs, zipPath, ExtractOptions{})
+}
+
+// ExtractWithOptions behaves like Extract, but lets the caller bound the
+// amount of data written to disk via opts.MaxExtractedSize.
+func ExtractWithOptions(fs billy.Filesystem, zipPath string, opts ExtractOptions) (d billy.Filesystem, err error, cleanup func()) {
+	dirName := ""
+	cleanup = func() {
+		if dirName != "" {
+			_ = os.RemoveAll(dirName)
+		}
+	}
+
+	f, err := fs.Open(zipPath)
+	if err != nil {
+		return
+	}
+
+	data, readErr := io.ReadAll(f)
+	closeErr := f.Close()
+	if readErr != nil {
+		err = readErr
+		return
+	}
+	if closeErr != nil {
+		err = closeErr
+		return
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return
+	}
+
+	dirName, err = util.TempDir(fs, useDefaultTempDir, tmpPrefix)
+	if err != nil {
+		return
+	}
+
+	unzipErr := unzip(fs, zr, dirName, opts)
+
+	var warnings *archiveutil.Warnings
+	if unzipErr != nil && !errors.As(unzipErr, &warnings) {
+		err = unzipErr
+		return
+	}
+
+	// unzipErr is either nil or a non-fatal *archiveutil.Warnings: the
+	// archive was fully extracted either way, so dirName is safe to
+	// chroot into. Hand the warning back to the caller alongside it.
+	d, err = fs.Chroot(dirName)
+	if err != nil {
+		return
+	}
+	err = unzipErr
+	return
+}
+
+// unzip adapts a *zip.Reader into an archiveutil.NextFunc and delegates
+// the actual walk-and-write to archiveutil.Extract.
+func unzip(fs billy.Filesystem, zr *zip.Reader, dstPath string, opts archiveutil.Options) error {
+	i := 0
+	return archiveutil.Extract(fs, dstPath, func() (archiveutil.Entry, error) {
+		if i >= len(zr.File) {
+			return archiveutil.Entry{}, io.EOF
+		}
+
+		f := zr.File[i]
+		i++
+
+		mode := f.Mode()
+		switch {
+		case mode&os.ModeSymlink != 0:
+			r, err := f.Open()
+			if err != nil {
+				return archiveutil.Entry{}, err
+			}
+			target, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return archiveutil.Entry{}, err
+			}
+
+			return archiveutil.Entry{
+				Name:     f.Name,
+				Mode:     mode.Perm(),
+				Typeflag: tar.TypeSymlink,
+				Linkname: string(target),
+			}, nil
+		case mode.IsDir():
+			return archiveutil.Entry{
+				Name:     f.Name,
+				Mode:     mode,
+				Typeflag: tar.TypeDir,
+			}, nil
+		default:
+			r, err := f.Open()
+			if err != nil {
+				return archiveutil.Entry{}, err
+			}
+
+			return archiveutil.Entry{
+				Name:     f.Name,
+				Mode:     mode.Perm(),
+				Typeflag: tar.TypeReg,
+				Reader:   &closeOnEOF{ReadCloser: r},
+			}, nil
+		}
+	}, opts)
+}
+
+// closeOnEOF closes the underlying zip entry reader as soon as it has
+// been fully consumed, since archiveutil.Extract only drains one entry's
+// Reader at a time and never calls Close itself.
+type closeOnEOF struct {
+	io.ReadCloser
+}
+
+func (c *closeOnEOF) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if err != nil {
+		_ = c.Close()
+	}
+	return n, err
+}
@@ -0,0 +1,111 @@
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+func writeZip(t *testing.T, entries map[string]string, symlinks map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, body := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%s): %v", name, err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+
+	for name, target := range symlinks {
+		hdr := &zip.FileHeader{Name: name}
+		hdr.SetMode(os.ModeSymlink | 0777)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatalf("CreateHeader(%s): %v", name, err)
+		}
+		if _, err := w.Write([]byte(target)); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeFixture(t *testing.T, fs billy.Filesystem, name string, data []byte) {
+	t.Helper()
+
+	f, err := fs.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("Write(%s): %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", name, err)
+	}
+}
+
+func TestExtractWritesFilesAndSymlinks(t *testing.T) {
+	fs := memfs.New()
+	writeFixture(t, fs, "fixture.zip", writeZip(t,
+		map[string]string{"hello.txt": "hello"},
+		map[string]string{"link": "hello.txt"},
+	))
+
+	d, err, cleanup := Extract(fs, "fixture.zip")
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	f, err := d.Open("hello.txt")
+	if err != nil {
+		t.Fatalf("Open(hello.txt): %v", err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("hello.txt contents = %q, want %q", got, "hello")
+	}
+
+	target, err := d.Readlink("link")
+	if err != nil {
+		t.Fatalf("Readlink(link): %v", err)
+	}
+	if target != "hello.txt" {
+		t.Fatalf("Readlink(link) = %q, want %q", target, "hello.txt")
+	}
+}
+
+func TestExtractRejectsPathEscape(t *testing.T) {
+	fs := memfs.New()
+	writeFixture(t, fs, "evil.zip", writeZip(t,
+		map[string]string{"../../etc/passwd": "pwned"},
+		nil,
+	))
+
+	_, err, cleanup := Extract(fs, "evil.zip")
+	defer cleanup()
+	if err == nil {
+		t.Fatal("expected Extract to reject a path escaping the destination, got nil error")
+	}
+}
@@ -0,0 +1,486 @@
+// Package overlayfs composes a read-only lower billy.Basic (typically
+// embedfs.EmbedFS) with a writable upper billy.Filesystem (typically
+// memfs.New()) into a single billy.Filesystem. This lets a fixture ship
+// as a read-only embed.FS while still supporting tests that need to
+// mutate it (simulate a commit, rewrite a ref, ...), without first
+// extracting it to disk.
+package overlayfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+const whiteoutPrefix = ".wh."
+
+// Overlay is a billy.Filesystem that reads through upper then lower,
+// and writes exclusively to upper. Deletions of a lower-only path are
+// recorded as a whiteout marker in upper rather than mutating lower.
+type Overlay struct {
+	lower billy.Basic
+	upper billy.Filesystem
+}
+
+// New returns a billy.Filesystem overlaying the writable upper on top
+// of the read-only lower.
+func New(lower billy.Basic, upper billy.Filesystem) *Overlay {
+	return &Overlay{lower: lower, upper: upper}
+}
+
+func whiteoutPath(name string) string {
+	dir, base := filepath.Split(filepath.Clean(name))
+	return filepath.Join(dir, whiteoutPrefix+base)
+}
+
+func (o *Overlay) isWhiteout(filename string) bool {
+	_, err := o.upper.Stat(whiteoutPath(filename))
+	return err == nil
+}
+
+func (o *Overlay) clearWhiteout(filename string) error {
+	wp := whiteoutPath(filename)
+	if _, err := o.upper.Stat(wp); err == nil {
+		return o.upper.Remove(wp)
+	}
+	return nil
+}
+
+func (o *Overlay) writeWhiteout(filename string) error {
+	if err := o.ensureUpperDir(filepath.Dir(filename)); err != nil {
+		return err
+	}
+
+	f, err := o.upper.Create(whiteoutPath(filename))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (o *Overlay) ensureUpperDir(dir string) error {
+	if dir == "" || dir == "." {
+		return nil
+	}
+	return o.upper.MkdirAll(dir, 0755)
+}
+
+// copyUp copies filename's contents and mode from lower into upper,
+// creating any missing parent directories in upper along the way.
+func (o *Overlay) copyUp(filename string) (err error) {
+	src, err := o.lower.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := o.lower.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := o.ensureUpperDir(filepath.Dir(filename)); err != nil {
+		return err
+	}
+
+	dst, err := o.upper.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := dst.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		return err
+	}
+
+	if cfs, ok := o.upper.(billy.Change); ok {
+		_ = cfs.Chmod(filename, fi.Mode())
+	}
+
+	return nil
+}
+
+func (o *Overlay) Create(filename string) (billy.File, error) {
+	return o.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (o *Overlay) Open(filename string) (billy.File, error) {
+	return o.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (o *Overlay) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		// O_CREATE without O_TRUNC (e.g. O_CREATE|O_APPEND|O_WRONLY, used
+		// to append to an existing reflog) must preserve whatever content
+		// already exists, so copy it up from lower first if upper doesn't
+		// have it yet. O_CREATE|O_TRUNC always starts from empty and
+		// needs no copy-up.
+		if flag&os.O_TRUNC == 0 && !o.isWhiteout(filename) {
+			if _, err := o.upper.Stat(filename); os.IsNotExist(err) {
+				if _, lowerErr := o.lower.Stat(filename); lowerErr == nil {
+					if err := o.copyUp(filename); err != nil {
+						return nil, err
+					}
+				}
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := o.clearWhiteout(filename); err != nil {
+			return nil, err
+		}
+		if err := o.ensureUpperDir(filepath.Dir(filename)); err != nil {
+			return nil, err
+		}
+		return o.upper.OpenFile(filename, flag, perm)
+	}
+
+	if o.isWhiteout(filename) {
+		return nil, os.ErrNotExist
+	}
+
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0
+	if !write {
+		f, err := o.upper.Open(filename)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		return o.lower.Open(filename)
+	}
+
+	if _, err := o.upper.Stat(filename); os.IsNotExist(err) {
+		if err := o.copyUp(filename); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return o.upper.OpenFile(filename, flag, perm)
+}
+
+func (o *Overlay) Stat(filename string) (os.FileInfo, error) {
+	if o.isWhiteout(filename) {
+		return nil, os.ErrNotExist
+	}
+
+	fi, err := o.upper.Stat(filename)
+	if err == nil {
+		return fi, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return o.lower.Stat(filename)
+}
+
+func (o *Overlay) Remove(filename string) error {
+	_, upperErr := o.upper.Stat(filename)
+	_, lowerErr := o.lower.Stat(filename)
+
+	if os.IsNotExist(upperErr) && os.IsNotExist(lowerErr) {
+		return os.ErrNotExist
+	}
+
+	if upperErr == nil {
+		if err := o.upper.Remove(filename); err != nil {
+			return err
+		}
+	}
+
+	if lowerErr == nil {
+		return o.writeWhiteout(filename)
+	}
+
+	return nil
+}
+
+func (o *Overlay) Rename(from, to string) error {
+	if o.isWhiteout(from) {
+		return os.ErrNotExist
+	}
+
+	if _, err := o.upper.Stat(from); os.IsNotExist(err) {
+		if err := o.copyUp(from); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := o.ensureUpperDir(filepath.Dir(to)); err != nil {
+		return err
+	}
+
+	if err := o.upper.Rename(from, to); err != nil {
+		return err
+	}
+
+	if err := o.clearWhiteout(to); err != nil {
+		return err
+	}
+
+	if _, err := o.lower.Stat(from); err == nil {
+		return o.writeWhiteout(from)
+	}
+
+	return nil
+}
+
+func (o *Overlay) MkdirAll(path string, perm os.FileMode) error {
+	if err := o.clearWhiteout(path); err != nil {
+		return err
+	}
+	return o.upper.MkdirAll(path, perm)
+}
+
+func (o *Overlay) ReadDir(path string) ([]os.FileInfo, error) {
+	merged := map[string]os.FileInfo{}
+	hidden := map[string]bool{}
+
+	upperEntries, err := o.upper.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range upperEntries {
+		name := fi.Name()
+		if strings.HasPrefix(name, whiteoutPrefix) {
+			hidden[strings.TrimPrefix(name, whiteoutPrefix)] = true
+			continue
+		}
+		merged[name] = fi
+	}
+
+	if dfs, ok := o.lower.(billy.Dir); ok {
+		lowerEntries, err := dfs.ReadDir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, fi := range lowerEntries {
+			name := fi.Name()
+			if hidden[name] {
+				continue
+			}
+			if _, exists := merged[name]; !exists {
+				merged[name] = fi
+			}
+		}
+	}
+
+	result := make([]os.FileInfo, 0, len(merged))
+	for _, fi := range merged {
+		result = append(result, fi)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+
+	return result, nil
+}
+
+func (o *Overlay) TempFile(dir, prefix string) (billy.File, error) {
+	if err := o.ensureUpperDir(dir); err != nil {
+		return nil, err
+	}
+	return o.upper.TempFile(dir, prefix)
+}
+
+func (o *Overlay) Symlink(target, link string) error {
+	sfs, ok := o.upper.(billy.Symlink)
+	if !ok {
+		return fmt.Errorf("overlayfs: upper filesystem does not support symlinks: %w", billy.ErrNotSupported)
+	}
+
+	if err := o.clearWhiteout(link); err != nil {
+		return err
+	}
+	if err := o.ensureUpperDir(filepath.Dir(link)); err != nil {
+		return err
+	}
+
+	return sfs.Symlink(target, link)
+}
+
+func (o *Overlay) Readlink(link string) (string, error) {
+	if o.isWhiteout(link) {
+		return "", os.ErrNotExist
+	}
+
+	if sfs, ok := o.upper.(billy.Symlink); ok {
+		target, err := sfs.Readlink(link)
+		if err == nil {
+			return target, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if sfs, ok := o.lower.(billy.Symlink); ok {
+		return sfs.Readlink(link)
+	}
+
+	return "", os.ErrNotExist
+}
+
+func (o *Overlay) Lstat(filename string) (os.FileInfo, error) {
+	if o.isWhiteout(filename) {
+		return nil, os.ErrNotExist
+	}
+
+	if sfs, ok := o.upper.(billy.Symlink); ok {
+		fi, err := sfs.Lstat(filename)
+		if err == nil {
+			return fi, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else if fi, err := o.upper.Stat(filename); err == nil {
+		return fi, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if sfs, ok := o.lower.(billy.Symlink); ok {
+		if fi, err := sfs.Lstat(filename); err == nil {
+			return fi, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return o.lower.Stat(filename)
+}
+
+func (o *Overlay) Join(elem ...string) string {
+	return o.upper.Join(elem...)
+}
+
+// Chroot returns a view of the overlay rooted at path. The lower layer
+// is wrapped in a path-prefixing adaptor since types such as
+// embedfs.EmbedFS do not implement billy.Chroot themselves.
+func (o *Overlay) Chroot(path string) (billy.Filesystem, error) {
+	upperRoot, err := o.upper.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(newPrefixed(o.lower, path), upperRoot), nil
+}
+
+func (o *Overlay) Root() string {
+	return o.upper.Root()
+}
+
+// Capabilities implements the billy.Capable interface.
+func (o *Overlay) Capabilities() billy.Capability {
+	return billy.DefaultCapabilities
+}
+
+// prefixed adapts a billy.Basic (and, if available, billy.Dir, plus
+// billy.Symlink when billy.Dir is also implemented; a Symlink-only
+// filesystem is adapted as a plain billy.Basic) so that every path is
+// joined with root before being
+// forwarded, giving a sub-rooted view without requiring the wrapped
+// filesystem to implement billy.Chroot.
+type prefixed struct {
+	inner billy.Basic
+	root  string
+}
+
+func newPrefixed(inner billy.Basic, root string) billy.Basic {
+	p := &prefixed{inner: inner, root: root}
+
+	_, hasDir := inner.(billy.Dir)
+	_, hasSymlink := inner.(billy.Symlink)
+	switch {
+	case hasDir && hasSymlink:
+		return &prefixedDirSymlink{prefixed: p}
+	case hasDir:
+		return &prefixedDir{prefixed: p}
+	default:
+		return p
+	}
+}
+
+func (p *prefixed) join(filename string) string {
+	return filepath.Join(p.root, filename)
+}
+
+func (p *prefixed) Create(filename string) (billy.File, error) {
+	return p.inner.Create(p.join(filename))
+}
+
+func (p *prefixed) Open(filename string) (billy.File, error) {
+	return p.inner.Open(p.join(filename))
+}
+
+func (p *prefixed) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return p.inner.OpenFile(p.join(filename), flag, perm)
+}
+
+func (p *prefixed) Stat(filename string) (os.FileInfo, error) {
+	return p.inner.Stat(p.join(filename))
+}
+
+func (p *prefixed) Rename(from, to string) error {
+	return p.inner.Rename(p.join(from), p.join(to))
+}
+
+func (p *prefixed) Remove(filename string) error {
+	return p.inner.Remove(p.join(filename))
+}
+
+func (p *prefixed) Join(elem ...string) string {
+	return p.inner.Join(elem...)
+}
+
+type prefixedDir struct {
+	*prefixed
+}
+
+func (p *prefixedDir) ReadDir(path string) ([]os.FileInfo, error) {
+	return p.inner.(billy.Dir).ReadDir(p.join(path))
+}
+
+func (p *prefixedDir) MkdirAll(path string, perm os.FileMode) error {
+	return p.inner.(billy.Dir).MkdirAll(p.join(path), perm)
+}
+
+type prefixedDirSymlink struct {
+	*prefixed
+}
+
+func (p *prefixedDirSymlink) ReadDir(path string) ([]os.FileInfo, error) {
+	return p.inner.(billy.Dir).ReadDir(p.join(path))
+}
+
+func (p *prefixedDirSymlink) MkdirAll(path string, perm os.FileMode) error {
+	return p.inner.(billy.Dir).MkdirAll(p.join(path), perm)
+}
+
+func (p *prefixedDirSymlink) Lstat(filename string) (os.FileInfo, error) {
+	return p.inner.(billy.Symlink).Lstat(p.join(filename))
+}
+
+func (p *prefixedDirSymlink) Symlink(target, link string) error {
+	return p.inner.(billy.Symlink).Symlink(target, p.join(link))
+}
+
+func (p *prefixedDirSymlink) Readlink(link string) (string, error) {
+	return p.inner.(billy.Symlink).Readlink(p.join(link))
+}
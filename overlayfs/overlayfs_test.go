@@ -0,0 +1,144 @@
+package overlayfs
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+func writeLower(t *testing.T, fs billy.Filesystem, name, contents string) {
+	t.Helper()
+
+	f, err := fs.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", name, err)
+	}
+	if _, err := f.Write([]byte(contents)); err != nil {
+		t.Fatalf("Write(%s): %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", name, err)
+	}
+}
+
+func readAll(t *testing.T, o *Overlay, name string) string {
+	t.Helper()
+
+	f, err := o.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll(%s): %v", name, err)
+	}
+	return string(data)
+}
+
+func TestOpenFileAppendCopiesUpLowerContentFirst(t *testing.T) {
+	lower := memfs.New()
+	writeLower(t, lower, "logs/HEAD", "line one\n")
+	upper := memfs.New()
+	o := New(lower, upper)
+
+	f, err := o.OpenFile("logs/HEAD", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write([]byte("line two\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := readAll(t, o, "logs/HEAD")
+	want := "line one\nline two\n"
+	if got != want {
+		t.Fatalf("logs/HEAD = %q, want %q", got, want)
+	}
+}
+
+func TestOpenFileCreateTruncDoesNotCopyUp(t *testing.T) {
+	lower := memfs.New()
+	writeLower(t, lower, "refs/heads/main", "old-sha\n")
+	upper := memfs.New()
+	o := New(lower, upper)
+
+	f, err := o.OpenFile("refs/heads/main", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write([]byte("new-sha\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := readAll(t, o, "refs/heads/main")
+	if got != "new-sha\n" {
+		t.Fatalf("refs/heads/main = %q, want %q", got, "new-sha\n")
+	}
+}
+
+func TestRemoveLowerOnlyFileWritesWhiteout(t *testing.T) {
+	lower := memfs.New()
+	writeLower(t, lower, "gone.txt", "bye")
+	upper := memfs.New()
+	o := New(lower, upper)
+
+	if err := o.Remove("gone.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	if _, err := o.Stat("gone.txt"); !os.IsNotExist(err) {
+		t.Fatalf("Stat(gone.txt) after Remove = %v, want os.ErrNotExist", err)
+	}
+
+	entries, err := o.ReadDir("")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, fi := range entries {
+		if fi.Name() == "gone.txt" {
+			t.Fatalf("ReadDir still lists gone.txt after Remove")
+		}
+	}
+}
+
+func TestReadDirMergesUpperAndLowerWithoutDuplicates(t *testing.T) {
+	lower := memfs.New()
+	writeLower(t, lower, "a.txt", "a")
+	writeLower(t, lower, "b.txt", "b")
+	upper := memfs.New()
+	o := New(lower, upper)
+
+	// Overwriting a lower-only file copies it up; it must appear once in
+	// the merged listing, not twice.
+	f, err := o.OpenFile("a.txt", os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	f.Close()
+
+	entries, err := o.ReadDir("")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	seen := map[string]int{}
+	for _, fi := range entries {
+		seen[fi.Name()]++
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if seen[name] != 1 {
+			t.Errorf("ReadDir lists %s %d times, want 1", name, seen[name])
+		}
+	}
+}
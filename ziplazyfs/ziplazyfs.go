@@ -0,0 +1,372 @@
+// Package ziplazyfs serves a billy.Filesystem directly from a zip
+// archive's central directory, without extracting anything up front.
+// Stat and ReadDir are answered purely from the parsed central
+// directory; Open only decompresses an entry the first time it is read
+// from. Combined with overlayfs, this gives fast, mutable fixtures even
+// for archives a test suite only ever touches a handful of files in.
+package ziplazyfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// spillThreshold is the uncompressed size above which a lazily read
+// entry is materialized into a temp file instead of memory.
+const spillThreshold = 32 << 20 // 32MiB
+
+// ZipLazyFS is a read-only billy.Filesystem backed by a *zip.Reader.
+type ZipLazyFS struct {
+	zr       *zip.Reader
+	files    map[string]*zip.File       // cleaned path -> entry
+	children map[string]map[string]bool // cleaned dir path -> immediate child basenames
+}
+
+// New opens the zip archive at zipPath and returns a billy.Filesystem
+// serving it lazily. The returned closer releases the underlying
+// os.File and must be called once the filesystem is no longer needed.
+func New(zipPath string) (billy.Filesystem, io.Closer, error) {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return newFromReader(zr), f, nil
+}
+
+func newFromReader(zr *zip.Reader) *ZipLazyFS {
+	z := &ZipLazyFS{
+		zr:       zr,
+		files:    make(map[string]*zip.File, len(zr.File)),
+		children: make(map[string]map[string]bool),
+	}
+
+	for _, zf := range zr.File {
+		clean := cleanPath(zf.Name)
+		if clean == "" {
+			continue
+		}
+		z.files[clean] = zf
+		z.registerPath(clean)
+
+		// Explicit directory entries ("foo/") need their own (possibly
+		// empty) entry in children, otherwise a dir with no files of its
+		// own is only known via z.files and ReadDir mistakes it for a
+		// regular file.
+		if zf.Mode().IsDir() {
+			if _, ok := z.children[clean]; !ok {
+				z.children[clean] = make(map[string]bool)
+			}
+		}
+	}
+
+	return z
+}
+
+func cleanPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// registerPath makes every ancestor of clean known as a directory
+// containing the next path segment as an immediate child.
+func (z *ZipLazyFS) registerPath(clean string) {
+	for clean != "" {
+		dir := path.Dir(clean)
+		if dir == "." {
+			dir = ""
+		}
+		base := path.Base(clean)
+
+		set, ok := z.children[dir]
+		if !ok {
+			set = make(map[string]bool)
+			z.children[dir] = set
+		}
+		set[base] = true
+
+		clean = dir
+	}
+}
+
+func (z *ZipLazyFS) Stat(filename string) (os.FileInfo, error) {
+	clean := cleanPath(filename)
+
+	if zf, ok := z.files[clean]; ok {
+		return zf.FileInfo(), nil
+	}
+	if _, ok := z.children[clean]; ok || clean == "" {
+		return &dirInfo{name: path.Base(clean)}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+func (z *ZipLazyFS) Lstat(filename string) (os.FileInfo, error) {
+	return z.Stat(filename)
+}
+
+func (z *ZipLazyFS) ReadDir(p string) ([]os.FileInfo, error) {
+	clean := cleanPath(p)
+
+	names, ok := z.children[clean]
+	if !ok {
+		if _, isFile := z.files[clean]; isFile {
+			return nil, fmt.Errorf("ziplazyfs: not a directory: %s", p)
+		}
+		return nil, os.ErrNotExist
+	}
+
+	infos := make([]os.FileInfo, 0, len(names))
+	for name := range names {
+		child := name
+		if clean != "" {
+			child = clean + "/" + name
+		}
+		fi, err := z.Stat(child)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, fi)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (z *ZipLazyFS) Open(filename string) (billy.File, error) {
+	return z.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (z *ZipLazyFS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_CREATE|os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+
+	clean := cleanPath(filename)
+	zf, ok := z.files[clean]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return newLazyFile(zf), nil
+}
+
+func (z *ZipLazyFS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (z *ZipLazyFS) MkdirAll(path string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (z *ZipLazyFS) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+func (z *ZipLazyFS) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (z *ZipLazyFS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (z *ZipLazyFS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (z *ZipLazyFS) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+func (z *ZipLazyFS) Readlink(link string) (string, error) {
+	return "", fmt.Errorf("ziplazyfs: not a symlink: %s: %w", link, os.ErrInvalid)
+}
+
+func (z *ZipLazyFS) Chroot(path string) (billy.Filesystem, error) {
+	return nil, fmt.Errorf("ziplazyfs: chroot not supported: %w", billy.ErrNotSupported)
+}
+
+func (z *ZipLazyFS) Root() string {
+	return "/"
+}
+
+// Capabilities implements the billy.Capable interface.
+func (z *ZipLazyFS) Capabilities() billy.Capability {
+	return billy.ReadCapability
+}
+
+// dirInfo is a synthetic os.FileInfo for directories implied by entry
+// paths, since a zip archive does not always contain explicit entries
+// for every ancestor directory.
+type dirInfo struct {
+	name string
+}
+
+func (d *dirInfo) Name() string       { return d.name }
+func (d *dirInfo) Size() int64        { return 0 }
+func (d *dirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (d *dirInfo) ModTime() time.Time { return time.Time{} }
+func (d *dirInfo) IsDir() bool        { return true }
+func (d *dirInfo) Sys() interface{}   { return nil }
+
+// lazyFile defers decompressing its zip.File until first read, and only
+// materializes the full content (to memory, or to a temp file past
+// spillThreshold) if random access via ReadAt or Seek is needed. pos
+// tracks how many bytes have been streamed through r so that, if a
+// caller mixes Read with a later Seek or ReadAt, materialize can fast
+// forward the newly built buf/spill to the same position instead of
+// rewinding it to the start.
+type lazyFile struct {
+	zf   *zip.File
+	name string
+
+	r   io.ReadCloser
+	pos int64
+
+	buf   *bytes.Reader
+	spill *os.File
+}
+
+func newLazyFile(zf *zip.File) *lazyFile {
+	return &lazyFile{zf: zf, name: zf.Name}
+}
+
+func (l *lazyFile) Name() string { return l.name }
+
+func (l *lazyFile) Read(p []byte) (int, error) {
+	if l.buf != nil {
+		return l.buf.Read(p)
+	}
+	if l.spill != nil {
+		return l.spill.Read(p)
+	}
+
+	if l.r == nil {
+		r, err := l.zf.Open()
+		if err != nil {
+			return 0, err
+		}
+		l.r = r
+	}
+
+	n, err := l.r.Read(p)
+	l.pos += int64(n)
+	return n, err
+}
+
+func (l *lazyFile) materialize() error {
+	if l.buf != nil || l.spill != nil {
+		return nil
+	}
+
+	if l.r != nil {
+		_ = l.r.Close()
+		l.r = nil
+	}
+
+	r, err := l.zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if l.zf.UncompressedSize64 > spillThreshold {
+		tmp, err := os.CreateTemp("", "ziplazyfs-")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(tmp, r); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		// Resume from wherever Read already streamed to via l.r, rather
+		// than silently rewinding to the start.
+		if _, err := tmp.Seek(l.pos, io.SeekStart); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		l.spill = tmp
+		return nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	l.buf = bytes.NewReader(data)
+	if _, err := l.buf.Seek(l.pos, io.SeekStart); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (l *lazyFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := l.materialize(); err != nil {
+		return 0, err
+	}
+	if l.buf != nil {
+		return l.buf.ReadAt(p, off)
+	}
+	return l.spill.ReadAt(p, off)
+}
+
+func (l *lazyFile) Seek(offset int64, whence int) (int64, error) {
+	if err := l.materialize(); err != nil {
+		return 0, err
+	}
+	if l.buf != nil {
+		return l.buf.Seek(offset, whence)
+	}
+	return l.spill.Seek(offset, whence)
+}
+
+func (l *lazyFile) Write(p []byte) (int, error) {
+	return 0, billy.ErrReadOnly
+}
+
+func (l *lazyFile) Truncate(size int64) error {
+	return billy.ErrReadOnly
+}
+
+func (l *lazyFile) Close() error {
+	var err error
+	if l.r != nil {
+		err = l.r.Close()
+	}
+	if l.spill != nil {
+		if cerr := l.spill.Close(); err == nil {
+			err = cerr
+		}
+		if rerr := os.Remove(l.spill.Name()); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+func (l *lazyFile) Lock() error   { return nil }
+func (l *lazyFile) Unlock() error { return nil }
@@ -0,0 +1,117 @@
+package ziplazyfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string, explicitDirs []string) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, dir := range explicitDirs {
+		hdr := &zip.FileHeader{Name: dir}
+		hdr.SetMode(os.ModeDir | 0755)
+		if _, err := zw.CreateHeader(hdr); err != nil {
+			t.Fatalf("CreateHeader(%s): %v", dir, err)
+		}
+	}
+
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%s): %v", name, err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	zr, err := zip.NewReader(r, int64(r.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	return zr
+}
+
+func TestReadDirOnImplicitDirectory(t *testing.T) {
+	z := newFromReader(buildZip(t, map[string]string{
+		"dir/a.txt": "a",
+		"dir/b.txt": "b",
+	}, nil))
+
+	infos, err := z.ReadDir("dir")
+	if err != nil {
+		t.Fatalf("ReadDir(dir): %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("ReadDir(dir) returned %d entries, want 2", len(infos))
+	}
+}
+
+func TestReadDirOnExplicitEmptyDirectory(t *testing.T) {
+	z := newFromReader(buildZip(t, nil, []string{"empty/"}))
+
+	infos, err := z.ReadDir("empty")
+	if err != nil {
+		t.Fatalf("ReadDir(empty): %v", err)
+	}
+	if len(infos) != 0 {
+		t.Fatalf("ReadDir(empty) returned %d entries, want 0", len(infos))
+	}
+
+	fi, err := z.Stat("empty")
+	if err != nil {
+		t.Fatalf("Stat(empty): %v", err)
+	}
+	if !fi.IsDir() {
+		t.Fatal("Stat(empty).IsDir() = false, want true")
+	}
+}
+
+func TestSeekAfterReadPreservesPosition(t *testing.T) {
+	z := newFromReader(buildZip(t, map[string]string{
+		"file.txt": "0123456789",
+	}, nil))
+
+	f, err := z.Open("file.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 4)
+	n, err := io.ReadFull(f, buf)
+	if err != nil || n != 4 {
+		t.Fatalf("ReadFull = %d, %v; want 4, nil", n, err)
+	}
+	if string(buf) != "0123" {
+		t.Fatalf("first 4 bytes = %q, want %q", buf, "0123")
+	}
+
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatalf("Seek(SeekCurrent): %v", err)
+	}
+	if pos != 4 {
+		t.Fatalf("Seek(0, SeekCurrent) after reading 4 bytes = %d, want 4", pos)
+	}
+
+	rest, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(rest) != "456789" {
+		t.Fatalf("remaining bytes = %q, want %q", rest, "456789")
+	}
+}